@@ -0,0 +1,189 @@
+// Copyright (c) 2016 OpenM++
+// This code is licensed under the MIT license (see LICENSE.txt for details)
+
+package oidc
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jwk is a single RSA key as published by the issuer's JWKS endpoint (RFC 7517).
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type jwksDoc struct {
+	Keys []jwk `json:"keys"`
+}
+
+// keySet fetches and periodically refreshes an issuer's JSON Web Key Set,
+// and validates RS256-signed JWT bearer tokens against it.
+type keySet struct {
+	jwksUri string
+	theLock sync.RWMutex
+	byKid   map[string]*rsa.PublicKey
+}
+
+func newKeySet(jwksUri string) *keySet {
+	return &keySet{jwksUri: jwksUri, byKid: map[string]*rsa.PublicKey{}}
+}
+
+// refresh re-fetches the JWKS document and replaces the cached key set.
+func (ks *keySet) refresh() error {
+
+	resp, err := http.Get(ks.jwksUri)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("Error: fail to fetch JWKS: " + resp.Status)
+	}
+
+	var doc jwksDoc
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return err
+	}
+
+	byKid := make(map[string]*rsa.PublicKey, len(doc.Keys))
+	for _, k := range doc.Keys {
+		if k.Kty != "RSA" || k.Kid == "" {
+			continue
+		}
+		pk, err := rsaPublicKey(k.N, k.E)
+		if err != nil {
+			continue
+		}
+		byKid[k.Kid] = pk
+	}
+
+	ks.theLock.Lock()
+	ks.byKid = byKid
+	ks.theLock.Unlock()
+	return nil
+}
+
+// refreshLoop periodically re-fetches the JWKS document until the process exits.
+func (ks *keySet) refreshLoop(interval time.Duration) {
+	for range time.Tick(interval) {
+		_ = ks.refresh() // keep using the previous key set on transient fetch errors
+	}
+}
+
+func rsaPublicKey(nb64, eb64 string) (*rsa.PublicKey, error) {
+
+	nb, err := base64.RawURLEncoding.DecodeString(nb64)
+	if err != nil {
+		return nil, err
+	}
+	eb, err := base64.RawURLEncoding.DecodeString(eb64)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(eb) > 8 {
+		return nil, errors.New("Error: invalid JWKS key, exponent too large")
+	}
+
+	eBuf := make([]byte, 8)
+	copy(eBuf[8-len(eb):], eb)
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(nb),
+		E: int(binary.BigEndian.Uint64(eBuf)),
+	}, nil
+}
+
+// verify checks a RS256-signed JWT's signature against the cached JWKS and returns its claims.
+func (ks *keySet) verify(tokenStr string) (*Claims, error) {
+
+	parts := strings.Split(tokenStr, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("Error: invalid JWT format")
+	}
+
+	header, err := decodeJwtJson(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	kid, _ := header["kid"].(string)
+
+	ks.theLock.RLock()
+	pk, ok := ks.byKid[kid]
+	ks.theLock.RUnlock()
+	if !ok {
+		return nil, errors.New("Error: unknown JWT key id: " + kid)
+	}
+
+	if err := verifyRS256(parts[0]+"."+parts[1], parts[2], pk); err != nil {
+		return nil, err
+	}
+
+	payload, err := decodeJwtJson(parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Claims{}
+	if v, ok := payload["sub"].(string); ok {
+		c.Subject = v
+	}
+	if v, ok := payload["email"].(string); ok {
+		c.Email = v
+	}
+	if v, ok := payload["exp"].(float64); ok {
+		c.Expiry = int64(v)
+	}
+	if v, ok := payload["groups"].([]interface{}); ok {
+		for _, g := range v {
+			if s, ok := g.(string); ok {
+				c.Groups = append(c.Groups, s)
+			}
+		}
+	}
+
+	if c.Expiry != 0 && time.Now().Unix() > c.Expiry {
+		return nil, errors.New("Error: JWT token expired")
+	}
+	return c, nil
+}
+
+// verifyRS256 checks a JWT's RS256 signature over signedPart against sigB64.
+func verifyRS256(signedPart, sigB64 string, pk *rsa.PublicKey) error {
+
+	sig, err := base64.RawURLEncoding.DecodeString(sigB64)
+	if err != nil {
+		return err
+	}
+	h := sha256.Sum256([]byte(signedPart))
+	return rsa.VerifyPKCS1v15(pk, crypto.SHA256, h[:], sig)
+}
+
+func decodeJwtJson(seg string) (map[string]interface{}, error) {
+
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
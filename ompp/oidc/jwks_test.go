@@ -0,0 +1,49 @@
+// Copyright (c) 2016 OpenM++
+// This code is licensed under the MIT license (see LICENSE.txt for details)
+
+package oidc
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestRsaPublicKey(t *testing.T) {
+
+	n := base64.RawURLEncoding.EncodeToString([]byte{0x01, 0x02, 0x03})
+
+	t.Run("valid exponent", func(t *testing.T) {
+		e := base64.RawURLEncoding.EncodeToString([]byte{0x01, 0x00, 0x01}) // 65537, the common RSA exponent
+		pk, err := rsaPublicKey(n, e)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if pk.E != 65537 {
+			t.Errorf("expected E = 65537, got %d", pk.E)
+		}
+	})
+
+	t.Run("oversized exponent is rejected", func(t *testing.T) {
+		e := base64.RawURLEncoding.EncodeToString(make([]byte, 9)) // longer than the 8-byte uint64 buffer
+		if _, err := rsaPublicKey(n, e); err == nil {
+			t.Fatal("expected error for oversized exponent, got nil")
+		}
+	})
+
+	t.Run("8-byte exponent fills the buffer exactly", func(t *testing.T) {
+		e := base64.RawURLEncoding.EncodeToString([]byte{0, 0, 0, 0, 0, 1, 0, 1})
+		pk, err := rsaPublicKey(n, e)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if pk.E != 65537 {
+			t.Errorf("expected E = 65537, got %d", pk.E)
+		}
+	})
+
+	t.Run("invalid base64 is rejected", func(t *testing.T) {
+		if _, err := rsaPublicKey("not-valid-base64!!", "AQAB"); err == nil {
+			t.Fatal("expected error for invalid modulus encoding, got nil")
+		}
+	})
+}
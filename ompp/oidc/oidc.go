@@ -0,0 +1,175 @@
+// Copyright (c) 2016 OpenM++
+// This code is licensed under the MIT license (see LICENSE.txt for details)
+
+// Package oidc implements a minimal OpenID Connect client: issuer discovery,
+// JWKS-backed access token verification and admin group membership checks.
+// It does not depend on any particular http router or session implementation,
+// those are left to the caller.
+package oidc
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Config is OIDC client configuration, normally populated from environment variables:
+// OIDC_ISSUER, OIDC_CLIENT_ID, OIDC_CLIENT_SECRET, OIDC_ADMIN_GROUPS,
+// each of which can be supplied instead as OIDC_ISSUER_FILE, OIDC_CLIENT_ID_FILE, etc.
+// to read the value from a file, e.g. a Kubernetes or Docker secret mount.
+type Config struct {
+	Issuer       string   // OIDC issuer URL, e.g. https://login.example.com/realms/openmpp
+	ClientID     string   // OAuth2 client id registered with the issuer
+	ClientSecret string   // OAuth2 client secret
+	AdminGroups  []string // groups claim values which grant admin access
+}
+
+// IsEnabled return true if enough configuration is present to run OIDC authentication.
+func (cfg Config) IsEnabled() bool {
+	return cfg.Issuer != "" && cfg.ClientID != ""
+}
+
+// LoadConfig reads OIDC configuration from environment variables.
+// Each of OIDC_ISSUER, OIDC_CLIENT_ID, OIDC_CLIENT_SECRET, OIDC_ADMIN_GROUPS
+// can be set directly or, with a _FILE suffix, as a path to a file holding the value.
+func LoadConfig() (Config, error) {
+
+	iss, err := envOrFile("OIDC_ISSUER")
+	if err != nil {
+		return Config{}, err
+	}
+	cid, err := envOrFile("OIDC_CLIENT_ID")
+	if err != nil {
+		return Config{}, err
+	}
+	secret, err := envOrFile("OIDC_CLIENT_SECRET")
+	if err != nil {
+		return Config{}, err
+	}
+	groups, err := envOrFile("OIDC_ADMIN_GROUPS")
+	if err != nil {
+		return Config{}, err
+	}
+
+	cfg := Config{Issuer: strings.TrimRight(iss, "/"), ClientID: cid, ClientSecret: secret}
+	for _, g := range strings.Split(groups, ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			cfg.AdminGroups = append(cfg.AdminGroups, g)
+		}
+	}
+	return cfg, nil
+}
+
+// envOrFile returns the value of the name env var, or, if name+"_FILE" is set instead,
+// the trimmed contents of the file it points to.
+func envOrFile(name string) (string, error) {
+
+	if v, ok := os.LookupEnv(name); ok {
+		return v, nil
+	}
+	if fp, ok := os.LookupEnv(name + "_FILE"); ok {
+		b, err := os.ReadFile(fp)
+		if err != nil {
+			return "", errors.New("Error: fail to read " + name + "_FILE: " + err.Error())
+		}
+		return strings.TrimSpace(string(b)), nil
+	}
+	return "", nil
+}
+
+// discoveryDoc is the subset of the OIDC discovery document (RFC: OpenID Connect Discovery 1.0)
+// that this client uses.
+type discoveryDoc struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	EndSessionEndpoint    string `json:"end_session_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JwksUri               string `json:"jwks_uri"`
+}
+
+// Provider is a discovered OIDC issuer, ready to build login/logout URLs and verify tokens.
+type Provider struct {
+	cfg  Config
+	doc  discoveryDoc
+	keys *keySet
+}
+
+// NewProvider discovers the issuer's endpoints and starts a background JWKS refresher.
+func NewProvider(cfg Config) (*Provider, error) {
+
+	if !cfg.IsEnabled() {
+		return nil, errors.New("Error: OIDC issuer and client id are required")
+	}
+
+	resp, err := http.Get(cfg.Issuer + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("Error: OIDC discovery failed: " + resp.Status)
+	}
+
+	var doc discoveryDoc
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, err
+	}
+	if doc.JwksUri == "" {
+		return nil, errors.New("Error: OIDC discovery document has no jwks_uri")
+	}
+
+	ks := newKeySet(doc.JwksUri)
+	if err := ks.refresh(); err != nil {
+		return nil, err
+	}
+	go ks.refreshLoop(15 * time.Minute)
+
+	return &Provider{cfg: cfg, doc: doc, keys: ks}, nil
+}
+
+// AuthorizationEndpoint return the issuer's authorization endpoint URL, empty if unknown.
+func (p *Provider) AuthorizationEndpoint() string { return p.doc.AuthorizationEndpoint }
+
+// TokenEndpoint return the issuer's token endpoint URL, empty if unknown.
+func (p *Provider) TokenEndpoint() string { return p.doc.TokenEndpoint }
+
+// EndSessionEndpoint return the issuer's end-session (logout) endpoint URL, empty if unknown.
+func (p *Provider) EndSessionEndpoint() string { return p.doc.EndSessionEndpoint }
+
+// ClientID return the OAuth2 client id this provider was configured with.
+func (p *Provider) ClientID() string { return p.cfg.ClientID }
+
+// ClientSecret return the OAuth2 client secret this provider was configured with.
+func (p *Provider) ClientSecret() string { return p.cfg.ClientSecret }
+
+// Claims is the subset of an OIDC id/access token this client understands.
+type Claims struct {
+	Subject string   // "sub" claim
+	Email   string   // "email" claim
+	Groups  []string // "groups" claim
+	Expiry  int64    // "exp" claim, unix seconds
+}
+
+// IsAdmin return true if claims' groups intersect the configured admin groups.
+func (p *Provider) IsAdmin(claims *Claims) bool {
+	for _, g := range claims.Groups {
+		for _, a := range p.cfg.AdminGroups {
+			if g == a {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// VerifyToken validates a RS256-signed bearer token against the issuer's JWKS
+// and returns its claims. It checks signature and expiry only, not audience,
+// as resource servers commonly accept tokens issued for other first-party clients.
+func (p *Provider) VerifyToken(tokenStr string) (*Claims, error) {
+	return p.keys.verify(tokenStr)
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2016 OpenM++
+// This code is licensed under the MIT license (see LICENSE.txt for details)
+
+package sftp
+
+import (
+	"io"
+	"os"
+	"time"
+
+	"github.com/pkg/sftp"
+)
+
+// virtualFs implements github.com/pkg/sftp Handlers, backed by a fresh catalog snapshot
+// fetched on every request so that catalog changes (including allModelsCloseHandler)
+// take effect immediately, without restarting the SFTP session.
+type virtualFs struct {
+	lister Lister
+}
+
+func newVirtualFs(lister Lister) *virtualFs {
+	return &virtualFs{lister: lister}
+}
+
+// Fileread serves a file read request; only real files under bin/log can be read.
+func (fs *virtualFs) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+
+	res, err := resolvePath(fs.lister(), r.Filepath)
+	if err != nil {
+		return nil, err
+	}
+	if res.isVirt {
+		return nil, errNotFound // not a file
+	}
+	return os.Open(res.real)
+}
+
+// Filewrite serves a file write request; writes are rejected for anything outside bin/log,
+// which resolvePath already enforces by only ever returning a real path under bin or log.
+func (fs *virtualFs) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+
+	res, err := resolvePath(fs.lister(), r.Filepath)
+	if err != nil {
+		return nil, err
+	}
+	if res.isVirt {
+		return nil, errReadOnly // cannot write to a model or root pseudo-directory
+	}
+	return os.OpenFile(res.real, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+}
+
+// Filecmd handles Remove, Rename, Mkdir, Rmdir and similar filesystem mutations.
+// Only plain files and directories already under a model's bin/log directory can be touched.
+func (fs *virtualFs) Filecmd(r *sftp.Request) error {
+
+	dirs := fs.lister()
+
+	res, err := resolvePath(dirs, r.Filepath)
+	if err != nil {
+		return err
+	}
+	if res.isVirt {
+		return errReadOnly
+	}
+
+	switch r.Method {
+	case "Remove":
+		return os.Remove(res.real)
+	case "Rmdir":
+		return os.Remove(res.real)
+	case "Mkdir":
+		return os.Mkdir(res.real, 0755)
+	case "Rename":
+		tgt, err := resolvePath(dirs, r.Target)
+		if err != nil {
+			return err
+		}
+		if tgt.isVirt {
+			return errReadOnly
+		}
+		return os.Rename(res.real, tgt.real)
+	case "Setstat":
+		return nil // accept, no-op: clients routinely send chmod/utimes after upload
+	default:
+		return errReadOnly
+	}
+}
+
+// Filelist handles List (directory listing), Stat and Lstat requests.
+func (fs *virtualFs) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+
+	res, err := resolvePath(fs.lister(), r.Filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	switch r.Method {
+	case "List":
+		if res.isVirt {
+			infos := make([]os.FileInfo, len(res.entries))
+			for i, name := range res.entries {
+				infos[i] = virtualDirInfo(name)
+			}
+			return listerAt(infos), nil
+		}
+		f, err := os.Open(res.real)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		infos, err := f.Readdir(-1)
+		if err != nil {
+			return nil, err
+		}
+		return listerAt(infos), nil
+
+	case "Stat", "Lstat":
+		if res.isVirt {
+			return listerAt([]os.FileInfo{virtualDirInfo(baseName(r.Filepath))}), nil
+		}
+		fi, err := os.Stat(res.real)
+		if err != nil {
+			return nil, err
+		}
+		return listerAt([]os.FileInfo{fi}), nil
+	}
+
+	return nil, errNotFound
+}
+
+// listerAt adapts a slice of os.FileInfo to sftp.ListerAt, as required by pkg/sftp.
+type listerAt []os.FileInfo
+
+func (l listerAt) ListAt(ls []os.FileInfo, off int64) (int, error) {
+	if off >= int64(len(l)) {
+		return 0, io.EOF
+	}
+	n := copy(ls, l[off:])
+	if n < len(ls) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// virtualDirInfo is a synthetic os.FileInfo for the root and per-model pseudo-directories,
+// which have no backing real directory.
+type virtualDirInfo string
+
+func (v virtualDirInfo) Name() string       { return string(v) }
+func (v virtualDirInfo) Size() int64        { return 0 }
+func (v virtualDirInfo) Mode() os.FileMode  { return os.ModeDir | 0555 }
+func (v virtualDirInfo) ModTime() time.Time { return time.Time{} }
+func (v virtualDirInfo) IsDir() bool        { return true }
+func (v virtualDirInfo) Sys() interface{}   { return nil }
+
+func baseName(p string) string {
+	for i := len(p) - 1; i >= 0; i-- {
+		if p[i] == '/' {
+			return p[i+1:]
+		}
+	}
+	return p
+}
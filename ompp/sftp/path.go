@@ -0,0 +1,96 @@
+// Copyright (c) 2016 OpenM++
+// This code is licensed under the MIT license (see LICENSE.txt for details)
+
+package sftp
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+)
+
+// errNotFound is returned for any path that does not resolve to a model, bin or log directory.
+var errNotFound = errors.New("Error: not found")
+
+// errReadOnly is returned for any write, rename or delete attempted outside of bin/log,
+// or for path traversal attempts that would escape the model directory.
+var errReadOnly = errors.New("Error: path is read-only")
+
+// resolved is a request path resolved against the current catalog snapshot.
+type resolved struct {
+	real    string // real filesystem path, empty if this is a synthetic (non-leaf) directory
+	isVirt  bool   // true for "/" and "/{model}": synthetic directories with no real path
+	entries []string
+}
+
+// resolvePath maps a client-visible SFTP path to a real filesystem path under one model's
+// bin or log directory. dirs is a fresh snapshot from Lister, fetched once per request.
+func resolvePath(dirs []ModelDir, reqPath string) (resolved, error) {
+
+	// split without resolving "..", so that a ".." under bin or log reaches the guard below
+	// instead of being silently resolved into some other virtual path
+	var parts []string
+	for _, p := range strings.Split(filepath.ToSlash(reqPath), "/") {
+		if p != "" && p != "." {
+			parts = append(parts, p)
+		}
+	}
+
+	if len(parts) == 0 {
+		names := make([]string, len(dirs))
+		for i := range dirs {
+			names[i] = dirs[i].Name
+		}
+		return resolved{isVirt: true, entries: names}, nil
+	}
+
+	var md *ModelDir
+	for i := range dirs {
+		if dirs[i].Name == parts[0] {
+			md = &dirs[i]
+			break
+		}
+	}
+	if md == nil {
+		return resolved{}, errNotFound
+	}
+
+	if len(parts) == 1 {
+		entries := []string{"bin"}
+		if md.LogDir != "" {
+			entries = append(entries, "log")
+		}
+		return resolved{isVirt: true, entries: entries}, nil
+	}
+
+	var base string
+	switch parts[1] {
+	case "bin":
+		base = md.BinDir
+	case "log":
+		if md.LogDir == "" {
+			return resolved{}, errNotFound
+		}
+		base = md.LogDir
+	default:
+		return resolved{}, errNotFound
+	}
+
+	real := filepath.Join(append([]string{base}, parts[2:]...)...)
+
+	// guard against ".." escaping the bin/log directory: the resolved real path
+	// must still be base itself or a descendant of it
+	absBase, err := filepath.Abs(base)
+	if err != nil {
+		return resolved{}, err
+	}
+	absReal, err := filepath.Abs(real)
+	if err != nil {
+		return resolved{}, err
+	}
+	if absReal != absBase && !strings.HasPrefix(absReal, absBase+string(filepath.Separator)) {
+		return resolved{}, errReadOnly
+	}
+
+	return resolved{real: absReal}, nil
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2016 OpenM++
+// This code is licensed under the MIT license (see LICENSE.txt for details)
+
+package sftp
+
+import "testing"
+
+func testDirs() []ModelDir {
+	return []ModelDir{
+		{Name: "modelOne", Digest: "aaa111", BinDir: "/models/one/bin", LogDir: "/models/one/log"},
+		{Name: "modelTwo", Digest: "bbb222", BinDir: "/models/two/bin"}, // no log directory
+	}
+}
+
+func TestResolvePathRoot(t *testing.T) {
+
+	r, err := resolvePath(testDirs(), "/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !r.isVirt || len(r.entries) != 2 {
+		t.Fatalf("expected a virtual root listing both models, got %+v", r)
+	}
+}
+
+func TestResolvePathModelDir(t *testing.T) {
+
+	r, err := resolvePath(testDirs(), "/modelOne")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !r.isVirt || len(r.entries) != 2 {
+		t.Fatalf("expected bin and log entries for a model with logging enabled, got %+v", r)
+	}
+
+	r, err = resolvePath(testDirs(), "/modelTwo")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !r.isVirt || len(r.entries) != 1 {
+		t.Fatalf("expected only bin for a model without a log directory, got %+v", r)
+	}
+}
+
+func TestResolvePathBinAndLog(t *testing.T) {
+
+	r, err := resolvePath(testDirs(), "/modelOne/bin/sub/input.bin")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.real != "/models/one/bin/sub/input.bin" {
+		t.Errorf("expected resolved path under bin, got %q", r.real)
+	}
+
+	if _, err := resolvePath(testDirs(), "/modelTwo/log"); err != errNotFound {
+		t.Fatalf("expected errNotFound for a disabled log directory, got %v", err)
+	}
+}
+
+func TestResolvePathUnknownModel(t *testing.T) {
+	if _, err := resolvePath(testDirs(), "/noSuchModel/bin"); err != errNotFound {
+		t.Fatalf("expected errNotFound for an unknown model, got %v", err)
+	}
+}
+
+func TestResolvePathTraversalIsBlocked(t *testing.T) {
+	if _, err := resolvePath(testDirs(), "/modelOne/bin/../../etc/passwd"); err != errReadOnly {
+		t.Fatalf("expected errReadOnly for a path escaping bin, got %v", err)
+	}
+}
+
+func TestResolvePathDuplicateNamesDisambiguatedByDigestSuffix(t *testing.T) {
+	dirs := []ModelDir{
+		{Name: "modelOne-aaa111", Digest: "aaa111", BinDir: "/models/one/bin"},
+		{Name: "modelOne-ccc333", Digest: "ccc333", BinDir: "/models/one-dup/bin"},
+	}
+
+	r, err := resolvePath(dirs, "/modelOne-ccc333/bin")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.isVirt || r.real != "/models/one-dup/bin" {
+		t.Fatalf("expected the digest-suffixed directory to resolve to its own model, got %+v", r)
+	}
+}
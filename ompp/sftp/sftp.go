@@ -0,0 +1,164 @@
+// Copyright (c) 2016 OpenM++
+// This code is licensed under the MIT license (see LICENSE.txt for details)
+
+// Package sftp serves model bin and log directories over SFTP: each model is exposed
+// as /{modelName}/bin and /{modelName}/log, mapped to the real binDir/logDir of that model.
+// The package does not know about the model catalog directly, it asks for a fresh snapshot
+// of it (via Lister) on every SFTP request so it reflects catalog changes immediately.
+package sftp
+
+import (
+	"errors"
+	"net"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// ModelDir is one model's bin and log directory, as exposed over SFTP.
+type ModelDir struct {
+	Name   string // directory name exposed under the SFTP root, unique even if the model name is not
+	Digest string // model digest
+	BinDir string // real directory containing model.exe and input scenarios
+	LogDir string // real directory containing model run logs, empty if log is disabled
+}
+
+// Lister returns a fresh snapshot of models to expose. It is called on every SFTP
+// request (read, write, list, stat and file commands), so catalog changes (including allModelsCloseHandler)
+// take effect on the next SFTP request without restarting the server.
+type Lister func() []ModelDir
+
+// PasswordAuth validates SSH password authentication, e.g. a short-lived access token
+// presented as the password. user is the SSH username offered by the client.
+type PasswordAuth func(user, password string) bool
+
+// PublicKeyAuth validates SSH public key authentication against keys registered for user.
+type PublicKeyAuth func(user string, key ssh.PublicKey) bool
+
+// Config is configuration of the SFTP gateway server.
+type Config struct {
+	Listen        string // listen address, e.g. ":6022"
+	HostKey       ssh.Signer
+	Lister        Lister
+	PasswordAuth  PasswordAuth
+	PublicKeyAuth PublicKeyAuth
+}
+
+// Server is a running SFTP gateway.
+type Server struct {
+	cfg      Config
+	sshCfg   *ssh.ServerConfig
+	listener net.Listener
+}
+
+// NewServer builds an SFTP gateway server from cfg. It does not start listening yet.
+func NewServer(cfg Config) (*Server, error) {
+
+	if cfg.Lister == nil {
+		return nil, errors.New("Error: sftp.Config.Lister is required")
+	}
+	if cfg.HostKey == nil {
+		return nil, errors.New("Error: sftp.Config.HostKey is required")
+	}
+
+	sshCfg := &ssh.ServerConfig{}
+	sshCfg.AddHostKey(cfg.HostKey)
+
+	if cfg.PasswordAuth != nil {
+		sshCfg.PasswordCallback = func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if cfg.PasswordAuth(c.User(), string(pass)) {
+				return &ssh.Permissions{}, nil
+			}
+			return nil, errors.New("Error: invalid credentials")
+		}
+	}
+	if cfg.PublicKeyAuth != nil {
+		sshCfg.PublicKeyCallback = func(c ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if cfg.PublicKeyAuth(c.User(), key) {
+				return &ssh.Permissions{}, nil
+			}
+			return nil, errors.New("Error: unauthorized public key")
+		}
+	}
+
+	return &Server{cfg: cfg, sshCfg: sshCfg}, nil
+}
+
+// ListenAndServe starts accepting SFTP connections. It blocks until the listener is closed.
+func (s *Server) ListenAndServe() error {
+
+	l, err := net.Listen("tcp", s.cfg.Listen)
+	if err != nil {
+		return err
+	}
+	s.listener = l
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handleConn(conn)
+	}
+}
+
+// Close stops accepting new SFTP connections.
+func (s *Server) Close() error {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+// handleConn performs the SSH handshake for one connection and, on success, serves a
+// single SFTP session backed by the virtual filesystem rooted at the model catalog.
+func (s *Server) handleConn(conn net.Conn) {
+	defer conn.Close()
+
+	sc, chans, reqs, err := ssh.NewServerConn(conn, s.sshCfg)
+	if err != nil {
+		return // handshake failure, e.g. bad credentials: drop silently like sshd does
+	}
+	defer sc.Close()
+
+	go ssh.DiscardRequests(reqs)
+
+	for newCh := range chans {
+		if newCh.ChannelType() != "session" {
+			newCh.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+
+		ch, chReqs, err := newCh.Accept()
+		if err != nil {
+			continue
+		}
+		go s.serveSession(sc.User(), ch, chReqs)
+	}
+}
+
+// serveSession waits for the "subsystem=sftp" request on a channel, then runs the SFTP
+// request server against a per-connection virtual filesystem.
+func (s *Server) serveSession(user string, ch ssh.Channel, reqs <-chan *ssh.Request) {
+	defer ch.Close()
+
+	for req := range reqs {
+		isSftp := req.Type == "subsystem" && len(req.Payload) >= 4 && string(req.Payload[4:]) == "sftp"
+		if req.WantReply {
+			req.Reply(isSftp, nil)
+		}
+		if !isSftp {
+			continue
+		}
+
+		fs := newVirtualFs(s.cfg.Lister)
+		srv := sftp.NewRequestServer(ch, sftp.Handlers{
+			FileGet:  fs,
+			FilePut:  fs,
+			FileCmd:  fs,
+			FileList: fs,
+		})
+		srv.Serve()
+		return
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright (c) 2016 OpenM++
+// This code is licensed under the MIT license (see LICENSE.txt for details)
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/openmpp/go/ompp/omppLog"
+)
+
+// watchDebounce is how long the watch loop waits after the last filesystem event
+// before applying a batch of changes, to avoid thrashing while archives are extracted.
+const watchDebounce = 500 * time.Millisecond
+
+// modelWatch is background watcher state of the model directory.
+// It is a process-wide singleton, similar to theCatalog and theRunStateCatalog.
+type modelWatch struct {
+	theLock    sync.Mutex        // protect state below
+	isOn       bool              // true if watcher goroutine is running
+	watcher    *fsnotify.Watcher // fsnotify watcher, nil if not running
+	doneC      chan struct{}     // closed by StopWatch to stop the watch loop
+	pathDigest map[string][]string
+}
+
+// theModelWatch is the model directory watcher singleton.
+var theModelWatch modelWatch
+
+// ModelWatchState is "public" state of the model directory watcher.
+type ModelWatchState struct {
+	IsWatchOn bool   // true if watcher is currently running
+	ModelDir  string // model directory being watched
+}
+
+// StartWatch starts background watching of mc.modelDir, and every subdirectory under it,
+// for create, remove and rename of .sqlite files. pathDigest is seeded from theModelFiles
+// so models already loaded by a prior RefreshSqlite are tracked from the start, not just
+// ones added after the watch begins.
+// It is safe to call if watch already running: in that case it does nothing and returns no error.
+func (mc *ModelCatalog) StartWatch() error {
+
+	theModelWatch.theLock.Lock()
+	defer theModelWatch.theLock.Unlock()
+
+	if theModelWatch.isOn {
+		return nil // already running
+	}
+
+	modelDir, isDir := mc.getModelDir()
+	if !isDir {
+		return errors.New("Error: model directory not exist or not accesible: " + modelDir)
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := addWatchDirs(w, modelDir); err != nil {
+		w.Close()
+		return err
+	}
+
+	theModelWatch.watcher = w
+	theModelWatch.doneC = make(chan struct{})
+	theModelWatch.pathDigest = pathDigestFromFiles(theModelFiles.all())
+	theModelWatch.isOn = true
+
+	go mc.watchLoop(w, theModelWatch.doneC)
+
+	omppLog.Log("Model directory watch started: ", modelDir)
+	return nil
+}
+
+// addWatchDirs walks root and adds a watch for root itself and every subdirectory under it,
+// so fsnotify sees create/remove/rename events for .sqlite files nested in subdirectories.
+func addWatchDirs(w *fsnotify.Watcher, root string) error {
+	return filepath.Walk(root, func(src string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return w.Add(src)
+		}
+		return nil
+	})
+}
+
+// pathDigestFromFiles turns a digest-to-path index (as returned by theModelFiles.all())
+// into the path-to-digests map pathDigest uses to look up what to remove on file delete.
+func pathDigestFromFiles(byDigest map[string]string) map[string][]string {
+	byPath := map[string][]string{}
+	for dgst, fp := range byDigest {
+		byPath[fp] = append(byPath[fp], dgst)
+	}
+	return byPath
+}
+
+// registerWatchPath records the digests backed by fp in pathDigest, merging with any already
+// registered for that path, so a later watcher-observed delete/rename of fp can remove exactly
+// those models. It is a no-op if the watcher is not currently running.
+func registerWatchPath(fp string, dgstLst []string) {
+	theModelWatch.theLock.Lock()
+	defer theModelWatch.theLock.Unlock()
+
+	if !theModelWatch.isOn {
+		return
+	}
+
+	existing := theModelWatch.pathDigest[fp]
+dgstLoop:
+	for _, dgst := range dgstLst {
+		for _, e := range existing {
+			if e == dgst {
+				continue dgstLoop
+			}
+		}
+		existing = append(existing, dgst)
+	}
+	theModelWatch.pathDigest[fp] = existing
+}
+
+// unregisterWatchDigest removes digest from pathDigest bookkeeping wherever it is listed, e.g.
+// after RemoveModel, so a later delete or rename of that file is no longer mapped to digest.
+// It does not stop a later create or write event on that file from adding the model back.
+// It is a no-op if the watcher is not currently running.
+func unregisterWatchDigest(digest string) {
+	theModelWatch.theLock.Lock()
+	defer theModelWatch.theLock.Unlock()
+
+	if !theModelWatch.isOn {
+		return
+	}
+
+	for fp, dgstLst := range theModelWatch.pathDigest {
+		for i, dgst := range dgstLst {
+			if dgst == digest {
+				theModelWatch.pathDigest[fp] = append(dgstLst[:i], dgstLst[i+1:]...)
+				break
+			}
+		}
+		if len(theModelWatch.pathDigest[fp]) == 0 {
+			delete(theModelWatch.pathDigest, fp)
+		}
+	}
+}
+
+// StopWatch stops background watching of the model directory, if it is currently running.
+func (mc *ModelCatalog) StopWatch() {
+
+	theModelWatch.theLock.Lock()
+	defer theModelWatch.theLock.Unlock()
+
+	if !theModelWatch.isOn {
+		return
+	}
+
+	close(theModelWatch.doneC)
+	if err := theModelWatch.watcher.Close(); err != nil {
+		omppLog.Log("Error: close model directory watcher error: " + err.Error())
+	}
+	theModelWatch.watcher = nil
+	theModelWatch.isOn = false
+
+	omppLog.Log("Model directory watch stopped")
+}
+
+// WatchState return "public" state of the model directory watcher.
+func (mc *ModelCatalog) WatchState() ModelWatchState {
+
+	theModelWatch.theLock.Lock()
+	isOn := theModelWatch.isOn
+	theModelWatch.theLock.Unlock()
+
+	modelDir, _ := mc.getModelDir()
+	return ModelWatchState{IsWatchOn: isOn, ModelDir: modelDir}
+}
+
+// watchLoop receives fsnotify events for modelDir, coalesces bursts of events on a short
+// debounce timer and applies incremental updates to the model catalog.
+func (mc *ModelCatalog) watchLoop(w *fsnotify.Watcher, done chan struct{}) {
+
+	pending := map[string]fsnotify.Op{}
+	var timer *time.Timer
+
+	for {
+		var timerC <-chan time.Time
+		if timer != nil {
+			timerC = timer.C
+		}
+
+		select {
+		case <-done:
+			return
+
+		case ev, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			if ev.Op&fsnotify.Create != 0 {
+				if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+					if err := addWatchDirs(w, ev.Name); err != nil {
+						omppLog.Log("Error: fail to watch new model subdirectory: ", ev.Name, " : ", err.Error())
+					}
+					continue
+				}
+			}
+			if !strings.EqualFold(filepath.Ext(ev.Name), ".sqlite") || filepath.Base(ev.Name) == indexFileName {
+				continue
+			}
+			pending[ev.Name] = pending[ev.Name] | ev.Op
+
+			if timer == nil {
+				timer = time.NewTimer(watchDebounce)
+			} else {
+				timer.Reset(watchDebounce)
+			}
+
+		case <-timerC:
+			timer = nil
+			batch := pending
+			pending = map[string]fsnotify.Op{}
+			mc.applyWatchEvents(batch)
+
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			omppLog.Log("Error: model directory watch error: " + err.Error())
+		}
+	}
+}
+
+// applyWatchEvents incrementally updates the model catalog for a coalesced batch of filesystem events:
+// on create or write it opens and appends the new model(s), on remove or rename it drops the entries
+// that were registered for that path.
+func (mc *ModelCatalog) applyWatchEvents(pending map[string]fsnotify.Op) {
+
+	changed := map[string]bool{}
+
+	for fp, op := range pending {
+
+		if op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+
+			theModelWatch.theLock.Lock()
+			dgstLst := theModelWatch.pathDigest[fp]
+			delete(theModelWatch.pathDigest, fp)
+			theModelWatch.theLock.Unlock()
+
+			for _, dgst := range dgstLst {
+				if mc.removeModelByDigest(dgst) {
+					omppLog.Log("Model removed from catalog: ", fp, " ", dgst)
+					changed[dgst] = true
+				}
+			}
+		}
+
+		if op&(fsnotify.Create|fsnotify.Write) != 0 {
+
+			dgstLst, err := mc.appendModelFile(fp)
+			if err != nil {
+				omppLog.Log("Error: fail to add model from: ", fp, " : ", err.Error())
+				continue
+			}
+			if len(dgstLst) > 0 {
+				theModelWatch.theLock.Lock()
+				theModelWatch.pathDigest[fp] = dgstLst
+				theModelWatch.theLock.Unlock()
+				omppLog.Log("Model added to catalog: ", fp)
+				for _, dgst := range dgstLst {
+					changed[dgst] = true
+				}
+			}
+		}
+	}
+
+	// update the search index for just the models that changed in this batch, instead of
+	// rebuilding it from every model in the catalog
+	for dgst := range changed {
+		if err := mc.UpdateSearchIndex(dgst); err != nil {
+			omppLog.Log("Error: fail to update search index: " + err.Error())
+		}
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright (c) 2016 OpenM++
+// This code is licensed under the MIT license (see LICENSE.txt for details)
+
+package main
+
+import "flag"
+
+// config is server configuration, populated from command line or ini file options.
+// Every field here is set from an -OpenM.* option parsed by loadConfig.
+type config struct {
+	rootDir           string // server root directory
+	pageMaxSize       int64  // default "page" size: row count to read parameters or output tables
+	doubleFmt         string // format to convert float or double value to string
+	runHistoryMaxSize int    // max number of model run states to keep in run list history
+	loginUrl          string // user login URL for UI
+	logoutUrl         string // user logout URL for UI
+	sftpListen        string // SFTP gateway listen address, e.g. ":6022"; empty disables the gateway
+}
+
+// theCfg is server configuration singleton, set once by loadConfig before the service starts.
+var theCfg = &config{}
+
+// loadConfig parses -OpenM.* command line and ini file options into theCfg.
+func loadConfig() {
+
+	flag.StringVar(&theCfg.rootDir, "OpenM.RootDir", "", "server root directory")
+	flag.Int64Var(&theCfg.pageMaxSize, "OpenM.RowPageMaxSize", 100, "default row page size for parameters and output tables")
+	flag.StringVar(&theCfg.doubleFmt, "OpenM.DoubleFormat", "%.15g", "format to convert float or double value to string")
+	flag.IntVar(&theCfg.runHistoryMaxSize, "OpenM.RunHistoryMaxSize", 100, "max number of model run states to keep in run list history")
+	flag.StringVar(&theCfg.loginUrl, "OpenM.LoginUrl", "", "user login URL for UI")
+	flag.StringVar(&theCfg.logoutUrl, "OpenM.LogoutUrl", "", "user logout URL for UI")
+	flag.StringVar(&theCfg.sftpListen, "OpenM.SftpListen", "", "SFTP gateway listen address, e.g. \":6022\"; empty disables the gateway")
+
+	flag.Parse()
+}
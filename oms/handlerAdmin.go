@@ -6,6 +6,8 @@ package main
 import (
 	"net/http"
 
+	"github.com/husobee/vestigo"
+
 	"github.com/openmpp/go/ompp/omppLog"
 )
 
@@ -19,12 +21,18 @@ type CatalogState struct {
 	LogoutUrl         string          // user logout URL for UI
 	ModelCatalogState ModelCatalogPub // "public" state of model catalog
 	RunCatalogState   RunCatalogPub   // "public" state of model run catalog
+	IndexState        IndexState      // "public" state of the full-text search index
+	IsAuthenticated   bool            // true if the caller has a valid OIDC session or bearer token
+	UserEmail         string          // authenticated user's email, empty if not authenticated
+	UserRoles         []string        // authenticated user's groups, empty if not authenticated
 }
 
 // serviceStateHandler return service state and configuration.
 // GET /api/service/state
 func serviceStateHandler(w http.ResponseWriter, r *http.Request) {
 
+	sess, isAuth := currentSession(r)
+
 	st := CatalogState{
 		RootDir:           theCfg.rootDir,
 		RowPageMaxSize:    theCfg.pageMaxSize,
@@ -34,6 +42,12 @@ func serviceStateHandler(w http.ResponseWriter, r *http.Request) {
 		LogoutUrl:         theCfg.logoutUrl,
 		ModelCatalogState: *theCatalog.toPublic(),
 		RunCatalogState:   *theRunStateCatalog.toPublic(),
+		IndexState:        theSearchCatalog.State(),
+		IsAuthenticated:   isAuth,
+	}
+	if isAuth {
+		st.UserEmail = sess.Email
+		st.UserRoles = sess.Groups
 	}
 	jsonResponse(w, r, st)
 }
@@ -42,6 +56,10 @@ func serviceStateHandler(w http.ResponseWriter, r *http.Request) {
 // POST /api/admin/all-models/refresh
 func allModelsRefreshHandler(w http.ResponseWriter, r *http.Request) {
 
+	if !isAdminRequest(w, r) {
+		return
+	}
+
 	// model directory required to build list of model sqlite files
 	modelDir, _ := theCatalog.getModelDir()
 	if modelDir == "" {
@@ -65,6 +83,10 @@ func allModelsRefreshHandler(w http.ResponseWriter, r *http.Request) {
 // POST /api/admin/all-models/close
 func allModelsCloseHandler(w http.ResponseWriter, r *http.Request) {
 
+	if !isAdminRequest(w, r) {
+		return
+	}
+
 	// close models catalog
 	modelDir, _ := theCatalog.getModelDir()
 
@@ -76,3 +98,59 @@ func allModelsCloseHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Location", "/api/admin/all-models/close/"+modelDir)
 	w.Header().Set("Content-Type", "text/plain")
 }
+
+// allModelsWatchStateHandler return current state of the model directory watcher.
+// GET /api/admin/all-models/watch-state
+func allModelsWatchStateHandler(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(w, r) {
+		return
+	}
+	jsonResponse(w, r, theCatalog.WatchState())
+}
+
+// allModelsWatchHandler turns background model directory watching on or off.
+// Watching incrementally updates the model catalog as .sqlite files are added or removed,
+// as an alternative to a full rescan by allModelsRefreshHandler.
+// POST /api/admin/all-models/watch/{on|off}
+func allModelsWatchHandler(w http.ResponseWriter, r *http.Request) {
+
+	if !isAdminRequest(w, r) {
+		return
+	}
+
+	onOff := vestigo.Param(r, "on|off")
+
+	switch onOff {
+	case "on":
+		if err := theCatalog.StartWatch(); err != nil {
+			omppLog.Log(err)
+			http.Error(w, "Failed to start model directory watch", http.StatusBadRequest)
+			return
+		}
+	case "off":
+		theCatalog.StopWatch()
+	default:
+		http.Error(w, "Invalid watch state, expected on or off: "+onOff, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Location", "/api/admin/all-models/watch/"+onOff)
+	w.Header().Set("Content-Type", "text/plain")
+}
+
+// allModelsReindexHandler forces a rebuild of the full-text search index from the current model catalog.
+// POST /api/admin/all-models/reindex
+func allModelsReindexHandler(w http.ResponseWriter, r *http.Request) {
+
+	if !isAdminRequest(w, r) {
+		return
+	}
+
+	if err := theCatalog.RebuildSearchIndex(); err != nil {
+		omppLog.Log(err)
+		http.Error(w, "Failed to rebuild search index", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Location", "/api/admin/all-models/reindex")
+	w.Header().Set("Content-Type", "text/plain")
+}
@@ -0,0 +1,191 @@
+// Copyright (c) 2016 OpenM++
+// This code is licensed under the MIT license (see LICENSE.txt for details)
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/openmpp/go/ompp/omppLog"
+)
+
+// oauthStateCookie holds the CSRF state value for the duration of the redirect round trip.
+const oauthStateCookie = "om-oauth-state"
+
+// authLoginHandler redirects the UI to the OIDC issuer's authorization endpoint.
+// GET /api/auth/login
+func authLoginHandler(w http.ResponseWriter, r *http.Request) {
+
+	if theAuth == nil {
+		http.Error(w, "OIDC authentication is not configured", http.StatusNotFound)
+		return
+	}
+
+	state := randomState()
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    state,
+		Path:     "/api/auth",
+		Expires:  time.Now().Add(10 * time.Minute),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	redirectUri := callbackUrl(r)
+	http.Redirect(w, r, loginRedirectUrl(redirectUri, state), http.StatusFound)
+}
+
+// authCallbackHandler exchanges the authorization code for tokens, validates the id token
+// and issues a session cookie for the UI.
+// GET /api/auth/callback
+func authCallbackHandler(w http.ResponseWriter, r *http.Request) {
+
+	if theAuth == nil {
+		http.Error(w, "OIDC authentication is not configured", http.StatusNotFound)
+		return
+	}
+
+	if errMsg := r.URL.Query().Get("error"); errMsg != "" {
+		http.Error(w, "OIDC login failed: "+errMsg, http.StatusBadRequest)
+		return
+	}
+
+	code := r.URL.Query().Get("code")
+	state := r.URL.Query().Get("state")
+	if code == "" || state == "" {
+		http.Error(w, "Invalid OIDC callback: missing code or state", http.StatusBadRequest)
+		return
+	}
+
+	c, err := r.Cookie(oauthStateCookie)
+	if err != nil || c.Value != state {
+		http.Error(w, "Invalid OIDC callback: state mismatch", http.StatusBadRequest)
+		return
+	}
+
+	idToken, err := exchangeCode(code, callbackUrl(r))
+	if err != nil {
+		omppLog.Log("Error: OIDC token exchange failed: " + err.Error())
+		http.Error(w, "OIDC token exchange failed", http.StatusBadGateway)
+		return
+	}
+
+	claims, err := theAuth.VerifyToken(idToken)
+	if err != nil {
+		omppLog.Log("Error: OIDC id token validation failed: " + err.Error())
+		http.Error(w, "OIDC id token validation failed", http.StatusUnauthorized)
+		return
+	}
+
+	issueSessionCookie(w, claims)
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// authLogoutHandler clears the local session cookie and, if the issuer advertises one,
+// redirects to its end-session endpoint.
+// GET /api/auth/logout
+func authLogoutHandler(w http.ResponseWriter, r *http.Request) {
+
+	clearSessionCookie(w)
+
+	if theAuth != nil && theAuth.EndSessionEndpoint() != "" {
+		http.Redirect(w, r, theAuth.EndSessionEndpoint(), http.StatusFound)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// authSftpKeyHandler registers the caller's SSH public key for the SFTP gateway, so they can
+// connect with key-based auth instead of presenting a short-lived access token as password.
+// Requires an authenticated admin session, the same as the other admin endpoints.
+// POST /api/auth/sftp-key
+func authSftpKeyHandler(w http.ResponseWriter, r *http.Request) {
+
+	if !isAdminRequest(w, r) {
+		return
+	}
+
+	sess, _ := currentSession(r)
+
+	b, err := readBody(r)
+	if err != nil || len(b) == 0 {
+		http.Error(w, "Missing public key body", http.StatusBadRequest)
+		return
+	}
+
+	if err := registerSftpKey(sess.Email, string(b)); err != nil {
+		omppLog.Log("Error: invalid public key: " + err.Error())
+		http.Error(w, "Invalid public key", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+}
+
+// readBody reads and returns the full request body.
+func readBody(r *http.Request) ([]byte, error) {
+	defer r.Body.Close()
+	return io.ReadAll(r.Body)
+}
+
+// tokenResponse is the subset of an OIDC token endpoint response this client reads.
+type tokenResponse struct {
+	IdToken string `json:"id_token"`
+}
+
+// exchangeCode swaps an authorization code for an id token at the issuer's token endpoint.
+func exchangeCode(code, redirectUri string) (string, error) {
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectUri},
+		"client_id":     {theAuth.ClientID()},
+		"client_secret": {theAuth.ClientSecret()},
+	}
+
+	resp, err := http.PostForm(theAuth.TokenEndpoint(), form)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var tr tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return "", err
+	}
+	if tr.IdToken == "" {
+		return "", oidcErrorf("token endpoint returned no id_token")
+	}
+	return tr.IdToken, nil
+}
+
+// callbackUrl reconstructs this server's own callback URL for the redirect_uri parameter.
+func callbackUrl(r *http.Request) string {
+
+	scheme := "http"
+	if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + "/api/auth/callback"
+}
+
+func randomState() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err) // no entropy source: cannot safely generate OAuth2 state
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+type oidcErr string
+
+func (e oidcErr) Error() string { return string(e) }
+
+func oidcErrorf(msg string) error { return oidcErr(msg) }
@@ -0,0 +1,75 @@
+// Copyright (c) 2016 OpenM++
+// This code is licensed under the MIT license (see LICENSE.txt for details)
+
+package main
+
+import (
+	"net/http"
+
+	"github.com/husobee/vestigo"
+
+	"github.com/openmpp/go/ompp/omppLog"
+)
+
+// modelRefreshHandler re-opens a single model's .sqlite file in place: closes the old db
+// connection and re-reads model_dic, langCodes, langMeta and matcher for that model only.
+// POST /api/admin/model/:digest-or-name/refresh
+func modelRefreshHandler(w http.ResponseWriter, r *http.Request) {
+
+	if !isAdminRequest(w, r) {
+		return
+	}
+
+	dn := vestigo.Param(r, "digest-or-name")
+
+	ch, err := theCatalog.RefreshModel(dn)
+	if err != nil {
+		omppLog.Log(err)
+		http.Error(w, "Failed to refresh model: "+dn, http.StatusBadRequest)
+		return
+	}
+	jsonResponse(w, r, ch)
+}
+
+// modelFileAddHandler opens an arbitrary .sqlite path under the model directory and,
+// if it holds a model not already in the catalog, appends it and returns its digest.
+// POST /api/admin/model-file?path=...
+func modelFileAddHandler(w http.ResponseWriter, r *http.Request) {
+
+	if !isAdminRequest(w, r) {
+		return
+	}
+
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		http.Error(w, "Missing path parameter", http.StatusBadRequest)
+		return
+	}
+
+	ch, err := theCatalog.AddModelFile(path)
+	if err != nil {
+		omppLog.Log(err)
+		http.Error(w, "Failed to add model file: "+path, http.StatusBadRequest)
+		return
+	}
+	jsonResponse(w, r, ch)
+}
+
+// modelDeleteHandler closes and removes a single model from the catalog by digest.
+// DELETE /api/admin/model/:digest
+func modelDeleteHandler(w http.ResponseWriter, r *http.Request) {
+
+	if !isAdminRequest(w, r) {
+		return
+	}
+
+	digest := vestigo.Param(r, "digest")
+
+	ch, err := theCatalog.RemoveModel(digest)
+	if err != nil {
+		omppLog.Log(err)
+		http.Error(w, "Failed to remove model: "+digest, http.StatusBadRequest)
+		return
+	}
+	jsonResponse(w, r, ch)
+}
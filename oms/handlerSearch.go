@@ -0,0 +1,35 @@
+// Copyright (c) 2016 OpenM++
+// This code is licensed under the MIT license (see LICENSE.txt for details)
+
+package main
+
+import (
+	"net/http"
+
+	"github.com/openmpp/go/ompp/omppLog"
+)
+
+// SearchResults is response of the cross-catalog full-text search.
+type SearchResults struct {
+	Query string      // search query as received from the client
+	Lang  string      // language code results were restricted to, if any
+	Hits  []SearchHit // matching models, parameters and output tables
+}
+
+// modelSearchHandler runs a full-text search across all models, parameters and output tables
+// in the catalog, backed by the fts5 index built by RefreshSqlite / allModelsReindexHandler.
+// GET /api/models/search?q=...&lang=...
+func modelSearchHandler(w http.ResponseWriter, r *http.Request) {
+
+	q := r.URL.Query().Get("q")
+	lang := r.URL.Query().Get("lang")
+
+	hits, err := Search(q, lang)
+	if err != nil {
+		omppLog.Log("Error: search query failed: ", q, " : ", err.Error())
+		http.Error(w, "Search query failed", http.StatusBadRequest)
+		return
+	}
+
+	jsonResponse(w, r, SearchResults{Query: q, Lang: lang, Hits: hits})
+}
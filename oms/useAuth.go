@@ -0,0 +1,221 @@
+// Copyright (c) 2016 OpenM++
+// This code is licensed under the MIT license (see LICENSE.txt for details)
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openmpp/go/ompp/oidc"
+	"github.com/openmpp/go/ompp/omppLog"
+)
+
+// sessionCookieName is the name of the signed session cookie set after a successful OIDC login.
+const sessionCookieName = "om-session"
+
+// sessionMaxAge is how long an issued session cookie stays valid.
+const sessionMaxAge = 8 * time.Hour
+
+// theAuth is the process-wide OIDC provider, nil if OIDC is not configured.
+var theAuth *oidc.Provider
+
+// theSessionKey signs session cookies issued by the callback handler. It is generated once
+// at startup: restarting the server invalidates any outstanding session cookies, which is
+// acceptable since tokens also carry their own expiry.
+var theSessionKey = randomKey()
+
+// InitAuth loads OIDC configuration from the environment and, if present, discovers the
+// issuer and switches theCfg.loginUrl/theCfg.logoutUrl to the UI login/logout flow.
+// If OIDC_ISSUER is not set, OIDC stays disabled and admin endpoints remain open, same as before.
+func InitAuth() error {
+
+	cfg, err := oidc.LoadConfig()
+	if err != nil {
+		return err
+	}
+	if !cfg.IsEnabled() {
+		omppLog.Log("OIDC authentication is not configured, admin endpoints are not protected")
+		return nil
+	}
+
+	p, err := oidc.NewProvider(cfg)
+	if err != nil {
+		return err
+	}
+	theAuth = p
+
+	theCfg.loginUrl = "/api/auth/login"
+	theCfg.logoutUrl = "/api/auth/logout"
+
+	omppLog.Log("OIDC authentication enabled, issuer: ", cfg.Issuer)
+	return nil
+}
+
+// session is the payload of a signed session cookie.
+type session struct {
+	Email  string
+	Groups []string
+	Expiry int64
+}
+
+// isAdmin returns true if the session belongs to a configured admin group.
+func (s session) isAdmin() bool {
+	if theAuth == nil {
+		return false
+	}
+	return theAuth.IsAdmin(&oidc.Claims{Email: s.Email, Groups: s.Groups})
+}
+
+// issueSessionCookie signs and sets a session cookie on the response.
+func issueSessionCookie(w http.ResponseWriter, claims *oidc.Claims) {
+
+	exp := time.Now().Add(sessionMaxAge)
+	val := encodeSession(session{Email: claims.Email, Groups: claims.Groups, Expiry: exp.Unix()})
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    val,
+		Path:     "/",
+		Expires:  exp,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// clearSessionCookie removes the session cookie.
+func clearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{Name: sessionCookieName, Value: "", Path: "/", MaxAge: -1})
+}
+
+// encodeSession serializes and signs a session into a cookie value: payload.signature, base64url.
+func encodeSession(s session) string {
+
+	payload := strings.Join([]string{s.Email, strings.Join(s.Groups, ","), strconv.FormatInt(s.Expiry, 10)}, "|")
+	p64 := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	sig := signValue(p64)
+	return p64 + "." + sig
+}
+
+// decodeSession verifies and parses a cookie value produced by encodeSession.
+func decodeSession(v string) (session, bool) {
+
+	parts := strings.SplitN(v, ".", 2)
+	if len(parts) != 2 {
+		return session{}, false
+	}
+	if !hmac.Equal([]byte(signValue(parts[0])), []byte(parts[1])) {
+		return session{}, false
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return session{}, false
+	}
+	fs := strings.SplitN(string(b), "|", 3)
+	if len(fs) != 3 {
+		return session{}, false
+	}
+	exp, err := strconv.ParseInt(fs[2], 10, 64)
+	if err != nil {
+		return session{}, false
+	}
+
+	s := session{Email: fs[0], Expiry: exp}
+	if fs[1] != "" {
+		s.Groups = strings.Split(fs[1], ",")
+	}
+	if time.Now().Unix() > s.Expiry {
+		return session{}, false
+	}
+	return s, true
+}
+
+func signValue(v string) string {
+	mac := hmac.New(sha256.New, theSessionKey)
+	mac.Write([]byte(v))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func randomKey() []byte {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err) // no entropy source: cannot safely sign sessions
+	}
+	return b
+}
+
+// currentSession returns the caller's session, from a bearer JWT or from the session cookie.
+// ok is false if there is no valid session, e.g. OIDC is disabled, no credentials were sent
+// or they failed to validate.
+func currentSession(r *http.Request) (session, bool) {
+
+	if theAuth == nil {
+		return session{}, false
+	}
+
+	if tok := bearerToken(r); tok != "" {
+		claims, err := theAuth.VerifyToken(tok)
+		if err != nil {
+			return session{}, false
+		}
+		return session{Email: claims.Email, Groups: claims.Groups, Expiry: claims.Expiry}, true
+	}
+
+	if c, err := r.Cookie(sessionCookieName); err == nil {
+		return decodeSession(c.Value)
+	}
+	return session{}, false
+}
+
+func bearerToken(r *http.Request) string {
+	h := r.Header.Get("Authorization")
+	if strings.HasPrefix(h, "Bearer ") {
+		return strings.TrimPrefix(h, "Bearer ")
+	}
+	return ""
+}
+
+// isAdminRequest checks the caller is an authenticated admin. If not, it writes a 401/403
+// response and returns false. If OIDC is not configured it allows the request through,
+// preserving the previous (unauthenticated) behavior of the admin endpoints.
+func isAdminRequest(w http.ResponseWriter, r *http.Request) bool {
+
+	if theAuth == nil {
+		return true
+	}
+
+	s, ok := currentSession(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	if !s.isAdmin() {
+		http.Error(w, "Forbidden: admin group membership required", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// loginRedirectUrl builds the issuer's authorization_endpoint URL for the authorization code flow.
+func loginRedirectUrl(redirectUri, state string) string {
+
+	if theAuth == nil {
+		return ""
+	}
+	q := url.Values{
+		"client_id":     {theAuth.ClientID()},
+		"response_type": {"code"},
+		"scope":         {"openid profile email groups"},
+		"redirect_uri":  {redirectUri},
+		"state":         {state},
+	}
+	return theAuth.AuthorizationEndpoint() + "?" + q.Encode()
+}
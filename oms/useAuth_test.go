@@ -0,0 +1,72 @@
+// Copyright (c) 2016 OpenM++
+// This code is licensed under the MIT license (see LICENSE.txt for details)
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEncodeDecodeSession(t *testing.T) {
+
+	s := session{Email: "user@example.com", Groups: []string{"admin", "viewer"}, Expiry: time.Now().Add(time.Hour).Unix()}
+
+	got, ok := decodeSession(encodeSession(s))
+	if !ok {
+		t.Fatal("expected decodeSession to succeed")
+	}
+	if got.Email != s.Email || got.Expiry != s.Expiry || len(got.Groups) != len(s.Groups) {
+		t.Fatalf("decoded session does not match original: got %+v, want %+v", got, s)
+	}
+	for i := range s.Groups {
+		if got.Groups[i] != s.Groups[i] {
+			t.Fatalf("decoded groups do not match: got %v, want %v", got.Groups, s.Groups)
+		}
+	}
+}
+
+func TestDecodeSessionNoGroups(t *testing.T) {
+
+	s := session{Email: "user@example.com", Expiry: time.Now().Add(time.Hour).Unix()}
+
+	got, ok := decodeSession(encodeSession(s))
+	if !ok {
+		t.Fatal("expected decodeSession to succeed")
+	}
+	if len(got.Groups) != 0 {
+		t.Errorf("expected no groups, got %v", got.Groups)
+	}
+}
+
+func TestDecodeSessionExpired(t *testing.T) {
+
+	s := session{Email: "user@example.com", Expiry: time.Now().Add(-time.Hour).Unix()}
+
+	if _, ok := decodeSession(encodeSession(s)); ok {
+		t.Fatal("expected decodeSession to reject an expired session")
+	}
+}
+
+func TestDecodeSessionTampered(t *testing.T) {
+
+	s := session{Email: "user@example.com", Expiry: time.Now().Add(time.Hour).Unix()}
+	v := encodeSession(s)
+
+	tampered := v[:len(v)-1] + "x"
+	if tampered == v {
+		t.Fatal("test setup failed to tamper the cookie value")
+	}
+	if _, ok := decodeSession(tampered); ok {
+		t.Fatal("expected decodeSession to reject a tampered signature")
+	}
+}
+
+func TestDecodeSessionMalformed(t *testing.T) {
+
+	for _, v := range []string{"", "no-dot-separator", "payload.", ".sig"} {
+		if _, ok := decodeSession(v); ok {
+			t.Errorf("expected decodeSession(%q) to fail", v)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright (c) 2016 OpenM++
+// This code is licensed under the MIT license (see LICENSE.txt for details)
+
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/text/language"
+
+	"github.com/openmpp/go/ompp/db"
+	"github.com/openmpp/go/ompp/omppLog"
+)
+
+// ModelFileChange describes the result of a per-model admin operation, for the UI to
+// patch its own copy of the catalog instead of doing a full reload.
+type ModelFileChange struct {
+	Digest string // digest of the model that was added, refreshed or removed
+	Name   string // model name
+	Path   string // path to the model.sqlite file, empty on remove
+}
+
+// RefreshModel re-opens the .sqlite file of a single model, identified by digest or name,
+// and re-reads model_dic, langCodes, langMeta and matcher. The old db connection is closed
+// unless another model still in the catalog shares it (one .sqlite file can hold more than one model).
+// db.Open and schema checks run outside of mc.theLock, which is only held to swap the entry.
+func (mc *ModelCatalog) RefreshModel(dn string) (ModelFileChange, error) {
+
+	mc.theLock.Lock()
+	idx, ok := mc.indexByDigestOrName(dn)
+	if !ok {
+		mc.theLock.Unlock()
+		return ModelFileChange{}, errors.New("Error: model not found: " + dn)
+	}
+	digest := mc.modelLst[idx].meta.Model.Digest
+	modelLogDir := mc.modelLst[idx].logDir
+	isLogDir := mc.modelLst[idx].isLogDir
+	mc.theLock.Unlock()
+
+	fp, ok := theModelFiles.get(digest)
+	if !ok {
+		return ModelFileChange{}, errors.New("Error: unknown file path for model: " + digest)
+	}
+
+	md, err := openModelFile(fp, modelLogDir, isLogDir, digest)
+	if err != nil {
+		return ModelFileChange{}, err
+	}
+
+	mc.theLock.Lock()
+
+	idx, ok = mc.indexByDigest(digest)
+	if !ok {
+		mc.theLock.Unlock()
+		md.dbConn.Close()
+		return ModelFileChange{}, errors.New("Error: model removed while it was being refreshed: " + digest)
+	}
+
+	oldConn := mc.modelLst[idx].dbConn
+	mc.modelLst[idx] = md
+	if !mc.isDbConnUsed(oldConn) {
+		if err := oldConn.Close(); err != nil {
+			omppLog.Log("Error: close db connection error: " + err.Error())
+		}
+	}
+	mc.theLock.Unlock()
+
+	// update full-text search index so search results reflect the refreshed model
+	if err := mc.UpdateSearchIndex(digest); err != nil {
+		omppLog.Log("Error: fail to update search index: " + err.Error())
+	}
+
+	return ModelFileChange{Digest: digest, Name: md.meta.Model.Name, Path: fp}, nil
+}
+
+// AddModelFile opens a single .sqlite file located under mc.modelDir and, if it holds a
+// model not already in the catalog, appends it, registers it with the directory watcher
+// (if running) and updates the search index.
+// It returns the digest that was registered.
+func (mc *ModelCatalog) AddModelFile(path string) (ModelFileChange, error) {
+
+	fp, err := mc.validateModelPath(path)
+	if err != nil {
+		return ModelFileChange{}, err
+	}
+
+	dgstLst, err := mc.appendModelFile(fp)
+	if err != nil {
+		return ModelFileChange{}, err
+	}
+	if len(dgstLst) == 0 {
+		return ModelFileChange{}, errors.New("Error: model already exist in the catalog: " + fp)
+	}
+
+	// keep the directory watcher's bookkeeping in sync so a later delete of fp is recognized
+	registerWatchPath(fp, dgstLst)
+
+	// update full-text search index so search results include the newly added model(s)
+	for _, dgst := range dgstLst {
+		if err := mc.UpdateSearchIndex(dgst); err != nil {
+			omppLog.Log("Error: fail to update search index: " + err.Error())
+		}
+	}
+
+	mb, ok := mc.modelBasicByDigest(dgstLst[0])
+	if !ok {
+		return ModelFileChange{Digest: dgstLst[0], Path: fp}, nil
+	}
+	return ModelFileChange{Digest: mb.digest, Name: mb.name, Path: fp}, nil
+}
+
+// RemoveModel closes the db connection and removes a single model from the catalog by digest,
+// clears it from the directory watcher's bookkeeping (if running) and updates the search index.
+func (mc *ModelCatalog) RemoveModel(digest string) (ModelFileChange, error) {
+
+	mb, ok := mc.modelBasicByDigest(digest)
+	if !ok {
+		return ModelFileChange{}, errors.New("Error: model not found: " + digest)
+	}
+
+	if !mc.removeModelByDigest(digest) {
+		return ModelFileChange{}, errors.New("Error: model not found: " + digest)
+	}
+
+	// drop the digest from the watcher's path bookkeeping; a later create or write event
+	// on the old file still opens it and adds this model back
+	unregisterWatchDigest(digest)
+
+	// update full-text search index so search results drop the removed model
+	if err := mc.UpdateSearchIndex(digest); err != nil {
+		omppLog.Log("Error: fail to update search index: " + err.Error())
+	}
+
+	return ModelFileChange{Digest: mb.digest, Name: mb.name}, nil
+}
+
+// validateModelPath checks that path is a .sqlite file located under mc.modelDir.
+func (mc *ModelCatalog) validateModelPath(path string) (string, error) {
+
+	modelDir, isDir := mc.getModelDir()
+	if !isDir {
+		return "", errors.New("Error: model directory not exist or not accesible: " + modelDir)
+	}
+	if !strings.EqualFold(filepath.Ext(path), ".sqlite") {
+		return "", errors.New("Error: expected path to a .sqlite file: " + path)
+	}
+	if filepath.Base(path) == indexFileName {
+		return "", errors.New("Error: path is the search index file, not a model database: " + path)
+	}
+
+	absDir, err := filepath.Abs(modelDir)
+	if err != nil {
+		return "", err
+	}
+	absFp, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if absFp != absDir && !strings.HasPrefix(absFp, absDir+string(filepath.Separator)) {
+		return "", errors.New("Error: path must be under model directory: " + path)
+	}
+	return absFp, nil
+}
+
+// openModelFile opens a db connection to a single model.sqlite file, validates the openM++
+// schema version and reads the model_dic row matching digest (or the first row, if digest is empty).
+func openModelFile(fp, modelLogDir string, isLogDir bool, digest string) (modelDef, error) {
+
+	dbc, _, err := db.Open(db.MakeSqliteDefault(fp), db.SQLiteDbDriver, false)
+	if err != nil {
+		return modelDef{}, err
+	}
+	nv, err := db.OpenmppSchemaVersion(dbc)
+	if err != nil || nv < db.MinSchemaVersion {
+		dbc.Close()
+		return modelDef{}, errors.New("Error: invalid database, likely not an openM++ database: " + fp)
+	}
+
+	dicLst, err := db.GetModelList(dbc)
+	if err != nil || len(dicLst) <= 0 {
+		dbc.Close()
+		return modelDef{}, errors.New("Error: empty database, no models found: " + fp)
+	}
+
+	row := -1
+	for k := range dicLst {
+		if digest == "" || dicLst[k].Digest == digest {
+			row = k
+			break
+		}
+	}
+	if row < 0 {
+		dbc.Close()
+		return modelDef{}, errors.New("Error: model digest not found in database: " + digest + " " + fp)
+	}
+
+	ls, err := db.GetLanguages(dbc)
+	if err != nil || ls == nil {
+		dbc.Close()
+		return modelDef{}, errors.New("Error: no languages found in database: " + fp)
+	}
+
+	ml := []string{}
+	lt := []language.Tag{}
+	for k := range ls.Lang {
+		if ls.Lang[k].LangCode == dicLst[row].DefaultLangCode {
+			ml = append([]string{ls.Lang[k].LangCode}, ml...)
+			lt = append([]language.Tag{language.Make(ls.Lang[k].LangCode)}, lt...)
+		} else {
+			ml = append(ml, ls.Lang[k].LangCode)
+			lt = append(lt, language.Make(ls.Lang[k].LangCode))
+		}
+	}
+
+	return modelDef{
+		dbConn:     dbc,
+		binDir:     filepath.Dir(fp),
+		logDir:     modelLogDir,
+		isLogDir:   isLogDir,
+		isMetaFull: false,
+		meta:       &db.ModelMeta{Model: dicLst[row]},
+		langCodes:  ml,
+		langMeta:   ls,
+		matcher:    language.NewMatcher(lt),
+	}, nil
+}
@@ -0,0 +1,373 @@
+// Copyright (c) 2016 OpenM++
+// This code is licensed under the MIT license (see LICENSE.txt for details)
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/openmpp/go/ompp/db"
+	"github.com/openmpp/go/ompp/omppLog"
+)
+
+// indexFileName is the auxiliary FTS5 index file kept under modelDir, next to the model.sqlite files.
+const indexFileName = ".openmpp-index.sqlite"
+
+// search row kinds stored in the fts5 index, one row per (model, kind, name, lang)
+const (
+	searchKindModel     = "model"
+	searchKindParameter = "parameter"
+	searchKindTable     = "table"
+)
+
+// searchCatalog is the process-wide full-text search index over all models in the catalog.
+// It is rebuilt from scratch by RefreshSqlite and by explicit reindex request, and updated
+// for a single model by UpdateSearchIndex after that model is added, refreshed or removed.
+type searchCatalog struct {
+	theLock   sync.Mutex // protect state below
+	indexPath string     // path to the .openmpp-index.sqlite file
+	sqlDb     *sql.DB    // connection to the index file, nil if index not built yet
+	modelCnt  int        // number of models indexed
+	paramCnt  int        // number of parameters indexed
+	tableCnt  int        // number of output tables indexed
+	rowCnt    int        // total number of fts5 rows
+}
+
+// theSearchCatalog is the full-text search index singleton.
+var theSearchCatalog searchCatalog
+
+// IndexState is "public" state of the full-text search index.
+type IndexState struct {
+	IsReady    bool   // true if index file is open and ready for search
+	IndexPath  string // path to the index file
+	ModelCount int    // number of models indexed
+	ParamCount int    // number of parameters indexed
+	TableCount int    // number of output tables indexed
+	RowCount   int    // total number of index rows
+}
+
+// State return "public" state of the search index.
+func (sc *searchCatalog) State() IndexState {
+	sc.theLock.Lock()
+	defer sc.theLock.Unlock()
+
+	return IndexState{
+		IsReady:    sc.sqlDb != nil,
+		IndexPath:  sc.indexPath,
+		ModelCount: sc.modelCnt,
+		ParamCount: sc.paramCnt,
+		TableCount: sc.tableCnt,
+		RowCount:   sc.rowCnt,
+	}
+}
+
+// RebuildSearchIndex rebuilds the cross-catalog full-text search index from the models
+// currently in mc.modelLst: model_dic, parameter_dic, table_dic and their _txt translation rows
+// are written into a fts5 virtual table in modelDir/.openmpp-index.sqlite.
+// This re-reads every model in the catalog, which can be expensive with hundreds of models:
+// UpdateSearchIndex is the incremental alternative used after a single model changes.
+func (mc *ModelCatalog) RebuildSearchIndex() error {
+
+	modelDir, isDir := mc.getModelDir()
+	if !isDir {
+		return fmt.Errorf("Error: model directory not exist or not accesible: %s", modelDir)
+	}
+	idxPath := filepath.Join(modelDir, indexFileName)
+
+	sdb, err := sql.Open("sqlite3", idxPath)
+	if err != nil {
+		return err
+	}
+
+	if _, err := sdb.Exec("DROP TABLE IF EXISTS model_search"); err != nil {
+		sdb.Close()
+		return err
+	}
+	if _, err := sdb.Exec(
+		`CREATE VIRTUAL TABLE model_search USING fts5(
+			model_digest UNINDEXED,
+			kind UNINDEXED,
+			name,
+			lang UNINDEXED,
+			description,
+			notes
+		)`); err != nil {
+		sdb.Close()
+		return err
+	}
+
+	for _, dg := range mc.allModelDigests() {
+
+		meta, dbc, ok := mc.fullModelMetaByDigest(dg)
+		if !ok {
+			continue
+		}
+		if _, err := indexModelRows(sdb, meta, dbc); err != nil {
+			omppLog.Log("Error: fail to index model: ", meta.Model.Name, " : ", err.Error())
+		}
+	}
+
+	theSearchCatalog.theLock.Lock()
+	if theSearchCatalog.sqlDb != nil {
+		theSearchCatalog.sqlDb.Close()
+	}
+	theSearchCatalog.sqlDb = sdb
+	theSearchCatalog.indexPath = idxPath
+	theSearchCatalog.theLock.Unlock()
+
+	if err := refreshSearchIndexCounts(sdb); err != nil {
+		omppLog.Log("Error: fail to compute search index counts: " + err.Error())
+	}
+
+	omppLog.Log("Search index rebuilt: ", idxPath)
+	return nil
+}
+
+// UpdateSearchIndex incrementally updates the search index for a single model by digest:
+// any rows already indexed for that digest are dropped and, if the model is still in the
+// catalog, re-inserted from its current parameter_dic/table_dic/_txt rows. If digest is no
+// longer in the catalog this just drops its rows, the same as after a model removal.
+// Unlike RebuildSearchIndex this does not touch any other model's rows, so refreshing, adding
+// or removing one model out of hundreds stays cheap.
+// If the index has not been built yet, it falls back to a full RebuildSearchIndex.
+func (mc *ModelCatalog) UpdateSearchIndex(digest string) error {
+
+	theSearchCatalog.theLock.Lock()
+	sdb := theSearchCatalog.sqlDb
+	theSearchCatalog.theLock.Unlock()
+
+	if sdb == nil {
+		return mc.RebuildSearchIndex()
+	}
+
+	if _, err := sdb.Exec("DELETE FROM model_search WHERE model_digest = ?", digest); err != nil {
+		return err
+	}
+
+	if meta, dbc, ok := mc.fullModelMetaByDigest(digest); ok {
+		if _, err := indexModelRows(sdb, meta, dbc); err != nil {
+			return err
+		}
+	}
+
+	return refreshSearchIndexCounts(sdb)
+}
+
+// indexModelRows writes fts5 rows for every language of one model's model_dic, parameter_dic
+// and table_dic rows into sdb, and returns the number of rows written. It assumes any rows
+// previously indexed for this model have already been removed by the caller.
+func indexModelRows(sdb *sql.DB, meta *db.ModelMeta, dbc *sql.DB) (int, error) {
+
+	rCnt := 0
+
+	langLst, _ := db.GetLanguages(dbc)
+	txt, err := db.GetModelText(dbc, meta.Model.ModelId, "")
+	if err != nil {
+		omppLog.Log("Error: fail to read model text, model: ", meta.Model.Name, " : ", err.Error())
+		txt = nil
+	}
+	if langLst == nil {
+		return 0, nil
+	}
+
+	// index model_dic rows, one per language
+	for k := range langLst.Lang {
+		descr, note := modelTextFor(txt, langLst.Lang[k].LangCode)
+		if err := insertSearchRow(sdb, meta.Model.Digest, searchKindModel, meta.Model.Name, langLst.Lang[k].LangCode, descr, note); err != nil {
+			omppLog.Log("Error: fail to index model: ", meta.Model.Name, " : ", err.Error())
+			continue
+		}
+		rCnt++
+	}
+
+	// index parameter_dic rows, one per language
+	paramLst, err := db.GetParameterList(dbc, meta.Model.ModelId)
+	if err == nil {
+		for p := range paramLst {
+			for k := range langLst.Lang {
+				descr, note := paramTextFor(txt, paramLst[p].ParamId, langLst.Lang[k].LangCode)
+				if err := insertSearchRow(sdb, meta.Model.Digest, searchKindParameter, paramLst[p].Name, langLst.Lang[k].LangCode, descr, note); err != nil {
+					omppLog.Log("Error: fail to index parameter: ", paramLst[p].Name, " : ", err.Error())
+					continue
+				}
+				rCnt++
+			}
+		}
+	}
+
+	// index table_dic rows, one per language
+	tblLst, err := db.GetTableList(dbc, meta.Model.ModelId)
+	if err == nil {
+		for t := range tblLst {
+			for k := range langLst.Lang {
+				descr, note := tableTextFor(txt, tblLst[t].TableId, langLst.Lang[k].LangCode)
+				if err := insertSearchRow(sdb, meta.Model.Digest, searchKindTable, tblLst[t].Name, langLst.Lang[k].LangCode, descr, note); err != nil {
+					omppLog.Log("Error: fail to index output table: ", tblLst[t].Name, " : ", err.Error())
+					continue
+				}
+				rCnt++
+			}
+		}
+	}
+
+	return rCnt, nil
+}
+
+// refreshSearchIndexCounts recomputes the cached row counts from aggregate queries over the
+// already-open index file, which is cheap even for a large index, unlike rereading model.sqlite files.
+func refreshSearchIndexCounts(sdb *sql.DB) error {
+
+	var mCnt, pCnt, tCnt, rCnt int
+	row := sdb.QueryRow(
+		`SELECT
+			COUNT(DISTINCT model_digest),
+			COUNT(DISTINCT CASE WHEN kind = ? THEN model_digest || '|' || name END),
+			COUNT(DISTINCT CASE WHEN kind = ? THEN model_digest || '|' || name END),
+			COUNT(*)
+		FROM model_search`,
+		searchKindParameter, searchKindTable)
+	if err := row.Scan(&mCnt, &pCnt, &tCnt, &rCnt); err != nil {
+		return err
+	}
+
+	theSearchCatalog.theLock.Lock()
+	theSearchCatalog.modelCnt = mCnt
+	theSearchCatalog.paramCnt = pCnt
+	theSearchCatalog.tableCnt = tCnt
+	theSearchCatalog.rowCnt = rCnt
+	theSearchCatalog.theLock.Unlock()
+	return nil
+}
+
+// CloseSearchIndex closes the search index connection, if open.
+func (sc *searchCatalog) CloseSearchIndex() {
+	sc.theLock.Lock()
+	defer sc.theLock.Unlock()
+
+	if sc.sqlDb != nil {
+		if err := sc.sqlDb.Close(); err != nil {
+			omppLog.Log("Error: close search index error: " + err.Error())
+		}
+		sc.sqlDb = nil
+	}
+}
+
+// SearchHit is one match returned by the full-text search.
+type SearchHit struct {
+	ModelDigest string  // model digest this row belongs to
+	Kind        string  // "model", "parameter" or "table"
+	Name        string  // model, parameter or output table name
+	Lang        string  // language code of the matched text
+	Snippet     string  // highlighted snippet of the description column
+	Rank        float64 // bm25 rank, lower (more negative) is a better match
+}
+
+// Search runs a full-text query across all indexed models, parameters and output tables.
+// lang, if not empty, restricts results to that language code.
+func Search(q string, lang string) ([]SearchHit, error) {
+
+	theSearchCatalog.theLock.Lock()
+	sdb := theSearchCatalog.sqlDb
+	theSearchCatalog.theLock.Unlock()
+
+	if sdb == nil {
+		return []SearchHit{}, nil // index not built yet
+	}
+	if strings.TrimSpace(q) == "" {
+		return []SearchHit{}, nil
+	}
+
+	stmt := `SELECT model_digest, kind, name, lang,
+			snippet(model_search, 4, '<b>', '</b>', '...', 24) AS descr_snip,
+			bm25(model_search) AS rank
+		FROM model_search
+		WHERE model_search MATCH ?`
+	args := []interface{}{q}
+	if lang != "" {
+		stmt += " AND (lang = ? OR lang = '')"
+		args = append(args, lang)
+	}
+	stmt += " ORDER BY rank LIMIT 100"
+
+	rows, err := sdb.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hits := []SearchHit{}
+	for rows.Next() {
+		h := SearchHit{}
+		if err := rows.Scan(&h.ModelDigest, &h.Kind, &h.Name, &h.Lang, &h.Snippet, &h.Rank); err != nil {
+			return nil, err
+		}
+		hits = append(hits, h)
+	}
+	return hits, rows.Err()
+}
+
+// insertSearchRow inserts one row into the fts5 index table.
+func insertSearchRow(sdb *sql.DB, digest, kind, name, lang, descr, note string) error {
+	_, err := sdb.Exec(
+		"INSERT INTO model_search (model_digest, kind, name, lang, description, notes) VALUES (?, ?, ?, ?, ?, ?)",
+		digest, kind, name, lang, descr, note)
+	return err
+}
+
+// modelTextFor, paramTextFor and tableTextFor pick the description and notes for a given
+// model/parameter/table id and language code out of the aggregate model text metadata.
+// txt may be nil if reading model text failed, in which case empty strings are returned.
+func modelTextFor(txt *db.ModelTxtMeta, langCode string) (descr string, note string) {
+	if txt == nil {
+		return "", ""
+	}
+	for k := range txt.ModelTxt {
+		if txt.ModelTxt[k].LangCode == langCode {
+			return txt.ModelTxt[k].Descr, txt.ModelTxt[k].Note
+		}
+	}
+	return "", ""
+}
+
+func paramTextFor(txt *db.ModelTxtMeta, paramId int, langCode string) (descr string, note string) {
+	if txt == nil {
+		return "", ""
+	}
+	for k := range txt.ParamTxt {
+		if txt.ParamTxt[k].ParamId == paramId && txt.ParamTxt[k].LangCode == langCode {
+			return txt.ParamTxt[k].Descr, txt.ParamTxt[k].Note
+		}
+	}
+	return "", ""
+}
+
+func tableTextFor(txt *db.ModelTxtMeta, tableId int, langCode string) (descr string, note string) {
+	if txt == nil {
+		return "", ""
+	}
+	for k := range txt.TableTxt {
+		if txt.TableTxt[k].TableId == tableId && txt.TableTxt[k].LangCode == langCode {
+			return txt.TableTxt[k].Descr, txt.TableTxt[k].Note
+		}
+	}
+	return "", ""
+}
+
+// fullModelMetaByDigest return model metadata and db connection for a model by digest.
+// It can be used to read full model, parameter and table lists for indexing.
+func (mc *ModelCatalog) fullModelMetaByDigest(digest string) (*db.ModelMeta, *sql.DB, bool) {
+	mc.theLock.Lock()
+	defer mc.theLock.Unlock()
+
+	idx, ok := mc.indexByDigest(digest)
+	if !ok {
+		return nil, nil, false
+	}
+	return mc.modelLst[idx].meta, mc.modelLst[idx].dbConn, true
+}
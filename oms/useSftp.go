@@ -0,0 +1,143 @@
+// Copyright (c) 2016 OpenM++
+// This code is licensed under the MIT license (see LICENSE.txt for details)
+
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/openmpp/go/ompp/omppLog"
+	ompSftp "github.com/openmpp/go/ompp/sftp"
+)
+
+// theSftpServer is the running SFTP gateway, nil if -OpenM.SftpListen is not configured.
+var theSftpServer *ompSftp.Server
+
+// theSftpKeys holds public keys admins registered for SSH public key authentication,
+// keyed by the email of the session that registered them.
+var theSftpKeys = struct {
+	theLock sync.Mutex
+	byUser  map[string]ssh.PublicKey
+}{byUser: map[string]ssh.PublicKey{}}
+
+// InitSftp starts the SFTP gateway over model bin and log directories, if theCfg.sftpListen
+// is set (command line or ini option -OpenM.SftpListen, e.g. ":6022"). Authentication reuses
+// the OIDC admin credentials: password auth accepts a short-lived OIDC access token,
+// public key auth accepts keys registered via authSftpKeyHandler.
+func InitSftp() error {
+
+	if theCfg.sftpListen == "" {
+		return nil
+	}
+	if theAuth == nil {
+		omppLog.Log("Warning: OIDC is not configured, SFTP gateway will reject all logins")
+	}
+
+	// ephemeral host key: regenerated on every restart, so clients must not pin its fingerprint
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+	hostKey, err := ssh.NewSignerFromKey(rsaKey)
+	if err != nil {
+		return err
+	}
+
+	srv, err := ompSftp.NewServer(ompSftp.Config{
+		Listen:        theCfg.sftpListen,
+		HostKey:       hostKey,
+		Lister:        sftpModelLister,
+		PasswordAuth:  sftpPasswordAuth,
+		PublicKeyAuth: sftpPublicKeyAuth,
+	})
+	if err != nil {
+		return err
+	}
+	theSftpServer = srv
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil {
+			omppLog.Log("Error: SFTP gateway stopped: " + err.Error())
+		}
+	}()
+
+	omppLog.Log("SFTP gateway listening at: ", theCfg.sftpListen)
+	return nil
+}
+
+// sftpModelLister is ompp/sftp.Lister backed by the live model catalog: it is called on every
+// SFTP request, so allModelsCloseHandler invalidates active sessions on their very next request.
+// The catalog allows more than one model with the same name but a different digest
+// (see indexByDigestOrName), so models sharing a name are exposed under a name-digest
+// directory instead, to keep every model reachable.
+func sftpModelLister() []ompSftp.ModelDir {
+
+	mbs := theCatalog.allModels()
+
+	nameCnt := map[string]int{}
+	for i := range mbs {
+		nameCnt[mbs[i].name]++
+	}
+
+	dirs := make([]ompSftp.ModelDir, len(mbs))
+	for i := range mbs {
+		dirName := mbs[i].name
+		if nameCnt[mbs[i].name] > 1 {
+			dirName = mbs[i].name + "-" + mbs[i].digest
+		}
+		dirs[i] = ompSftp.ModelDir{Name: dirName, Digest: mbs[i].digest, BinDir: mbs[i].binDir}
+		if mbs[i].isLogDir {
+			dirs[i].LogDir = mbs[i].logDir
+		}
+	}
+	return dirs
+}
+
+// sftpPasswordAuth validates the SSH password as a bearer-style OIDC access token belonging
+// to an admin group member, the same credential accepted by the REST admin endpoints.
+func sftpPasswordAuth(user, password string) bool {
+
+	if theAuth == nil {
+		return false
+	}
+	claims, err := theAuth.VerifyToken(password)
+	if err != nil {
+		return false
+	}
+	return theAuth.IsAdmin(claims)
+}
+
+// sftpPublicKeyAuth validates the SSH public key against keys registered for user by
+// authSftpKeyHandler, which itself requires an authenticated admin session.
+// It rejects every key if OIDC is not configured.
+func sftpPublicKeyAuth(user string, key ssh.PublicKey) bool {
+
+	if theAuth == nil {
+		return false
+	}
+
+	theSftpKeys.theLock.Lock()
+	reg, ok := theSftpKeys.byUser[user]
+	theSftpKeys.theLock.Unlock()
+
+	return ok && string(reg.Marshal()) == string(key.Marshal())
+}
+
+// registerSftpKey records pubKeyLine (an "authorized_keys"-format public key) as the SFTP
+// credential for user. Replaces any previously registered key for that user.
+func registerSftpKey(user string, pubKeyLine string) error {
+
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(pubKeyLine))
+	if err != nil {
+		return err
+	}
+
+	theSftpKeys.theLock.Lock()
+	theSftpKeys.byUser[user] = key
+	theSftpKeys.theLock.Unlock()
+	return nil
+}